@@ -0,0 +1,156 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e_node
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pluginapi "k8s.io/kubernetes/pkg/kubelet/apis/deviceplugin/v1beta1"
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+// fakeDevicePlugin is a minimal kubelet device plugin, speaking the real
+// device plugin registration and Allocate protocol, used to back the GPU
+// sharing and compute-policy tests with an actual plugin instead of having
+// the test pod author its own env vars. The kubelet's device manager is the
+// one calling allocateEnv and wiring the result into the container, so these
+// tests exercise the same path a real vendor plugin would.
+type fakeDevicePlugin struct {
+	resourceName v1.ResourceName
+	deviceIDs    []string
+	allocateEnv  func(deviceIDs []string) map[string]string
+
+	socket string
+	server *grpc.Server
+}
+
+// newFakeDevicePlugin builds a plugin that will advertise deviceCount
+// devices under resourceName. allocateEnv is called by Allocate with the
+// device IDs the kubelet picked for a container's share of resourceName, and
+// its result is injected into that container's environment.
+func newFakeDevicePlugin(resourceName v1.ResourceName, deviceCount int, allocateEnv func(deviceIDs []string) map[string]string) *fakeDevicePlugin {
+	base := strings.NewReplacer("/", "-", ".", "-").Replace(string(resourceName))
+	deviceIDs := make([]string, deviceCount)
+	for i := range deviceIDs {
+		deviceIDs[i] = fmt.Sprintf("%s-%d", base, i)
+	}
+	return newFakeDevicePluginWithIDs(resourceName, deviceIDs, allocateEnv)
+}
+
+// newFakeDevicePluginWithIDs is like newFakeDevicePlugin, but lets the caller
+// pick the advertised device IDs themselves instead of generic numbered ones.
+// This is what lets allocateEnv report which specific device - e.g. a MIG
+// slice's profile name - the kubelet actually picked for a container, via
+// containerReq.DevicesIDs, rather than a value the plugin can't distinguish
+// from any other.
+func newFakeDevicePluginWithIDs(resourceName v1.ResourceName, deviceIDs []string, allocateEnv func(deviceIDs []string) map[string]string) *fakeDevicePlugin {
+	base := strings.NewReplacer("/", "-", ".", "-").Replace(string(resourceName))
+	return &fakeDevicePlugin{
+		resourceName: resourceName,
+		deviceIDs:    deviceIDs,
+		allocateEnv:  allocateEnv,
+		socket:       filepath.Join(pluginapi.DevicePluginPath, base+".sock"),
+	}
+}
+
+// Start serves the plugin's gRPC endpoint and registers it with the local
+// kubelet, mirroring the two-step handshake (listen, then Register against
+// the kubelet's well-known registration socket) every real device plugin
+// performs.
+func (p *fakeDevicePlugin) Start() error {
+	os.Remove(p.socket)
+	lis, err := net.Listen("unix", p.socket)
+	if err != nil {
+		return err
+	}
+
+	p.server = grpc.NewServer()
+	pluginapi.RegisterDevicePluginServer(p.server, p)
+	go p.server.Serve(lis)
+
+	conn, err := grpc.Dial(pluginapi.KubeletSocket, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(10*time.Second),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+	if err != nil {
+		p.server.Stop()
+		return err
+	}
+	defer conn.Close()
+
+	_, err = pluginapi.NewRegistrationClient(conn).Register(context.Background(), &pluginapi.RegisterRequest{
+		Version:      pluginapi.Version,
+		Endpoint:     filepath.Base(p.socket),
+		ResourceName: string(p.resourceName),
+	})
+	if err != nil {
+		p.server.Stop()
+		return err
+	}
+	return nil
+}
+
+// Stop tears down the plugin's gRPC server. The kubelet notices the socket
+// disappearing and removes the resource from node capacity/allocatable on
+// its own, so callers don't need to undo any node patch themselves.
+func (p *fakeDevicePlugin) Stop() {
+	if p.server != nil {
+		p.server.Stop()
+	}
+	os.Remove(p.socket)
+}
+
+func (p *fakeDevicePlugin) GetDevicePluginOptions(ctx context.Context, e *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
+	return &pluginapi.DevicePluginOptions{}, nil
+}
+
+func (p *fakeDevicePlugin) ListAndWatch(e *pluginapi.Empty, stream pluginapi.DevicePlugin_ListAndWatchServer) error {
+	devices := make([]*pluginapi.Device, 0, len(p.deviceIDs))
+	for _, id := range p.deviceIDs {
+		devices = append(devices, &pluginapi.Device{ID: id, Health: pluginapi.Healthy})
+	}
+	if err := stream.Send(&pluginapi.ListAndWatchResponse{Devices: devices}); err != nil {
+		return err
+	}
+	// Block until the plugin is stopped (server.Stop() tears down the stream).
+	<-stream.Context().Done()
+	return nil
+}
+
+func (p *fakeDevicePlugin) Allocate(ctx context.Context, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	resp := &pluginapi.AllocateResponse{}
+	for _, containerReq := range req.ContainerRequests {
+		containerResp := &pluginapi.ContainerAllocateResponse{}
+		if p.allocateEnv != nil {
+			containerResp.Envs = p.allocateEnv(containerReq.DevicesIDs)
+		}
+		resp.ContainerResponses = append(resp.ContainerResponses, containerResp)
+	}
+	return resp, nil
+}
+
+func (p *fakeDevicePlugin) PreStartContainer(ctx context.Context, req *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+	return &pluginapi.PreStartContainerResponse{}, nil
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package e2e_node
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net/http"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -33,6 +36,188 @@ import (
 
 const acceleratorsFeatureGate = "Accelerators=true"
 
+// gpuWorkloadImage, when set, points at an image bundling a tiny real
+// inference model (e.g. BERT-tiny or MobileNet). Opting in enables the GPU
+// workload test, which runs that model on the allocated GPU instead of only
+// checking for device files. CI without GPUs leaves this unset and the test
+// skips cleanly.
+var gpuWorkloadImage = flag.String("gpu-workload-image", "", "Image bundling a small real inference workload used to functionally verify allocated GPUs. If unset, the workload verification test is skipped.")
+
+// gpuWorkloadMinThroughput is the floor, in inferences/sec, the workload
+// image must clear for the test to pass.
+const gpuWorkloadMinThroughput = 50
+
+const gpuWorkloadTimeout = 2 * time.Minute
+
+// computePolicyAnnotation lets a pod request how the device plugin under
+// test should time-slice compute on a GPU it shares with other pods.
+const computePolicyAnnotation = "gpu.alpha.kubernetes.io/compute-policy"
+
+const (
+	computePolicyFixedShare       = "fixed-share"
+	computePolicyBurstShare       = "burst-share"
+	computePolicyNativeBurstShare = "native-burst-share"
+)
+
+// fixedShareComputeLimit is the percentage of SM compute a fixed-share pod is
+// entitled to when sharing a GPU with exactly one other fixed-share pod.
+const fixedShareComputeLimit = 50
+
+// computePolicyFixedShareResourceName, computePolicyBurstShareResourceName
+// and computePolicyNativeBurstShareResourceName are fake extended resources,
+// each backed by its own fakeDevicePlugin, used to exercise a
+// compute-policy-aware plugin's Allocate hook instead of having the pod
+// author its own CUDA_COMPUTE_LIMIT/GPU_BURST env vars.
+// computePolicyUnsupportedResourceName is deliberately never advertised by
+// any plugin, so a pod requesting it is rejected by the kubelet's own
+// resource-insufficiency admission check - the only mechanism in this file
+// that actually drives a pod to PodFailed.
+const (
+	computePolicyFixedShareResourceName       = v1.ResourceName("nvidia.com/compute-fixed-share")
+	computePolicyBurstShareResourceName       = v1.ResourceName("nvidia.com/compute-burst-share")
+	computePolicyNativeBurstShareResourceName = v1.ResourceName("nvidia.com/compute-native-burst-share")
+	computePolicyUnsupportedResourceName      = v1.ResourceName("nvidia.com/compute-unsupported-policy")
+)
+
+// topologyManagerBestEffortPolicy mirrors the topology manager policy names
+// accepted by the KubeletConfiguration's TopologyManagerPolicy field.
+const topologyManagerBestEffortPolicy = "best-effort"
+
+// gpuMemResourceName is the extended resource advertised by gpushare-style
+// device plugins that slice a single physical GPU into memory units instead
+// of whole devices.
+const gpuMemResourceName = v1.ResourceName("aliyun.com/gpu-mem")
+
+// gpuMemUnitMiB is the amount of GPU memory, in MiB, a single unit of
+// gpuMemResourceName represents.
+const gpuMemUnitMiB = int64(1024)
+
+// acceleratorProfile describes how to exercise a single accelerator vendor's
+// extended resource through the same capacity/rejection flow:
+// which extended resource advertises it, what device files the container
+// should see, which env vars the device plugin is expected to set, and which
+// image can verify the above.
+type acceleratorProfile struct {
+	name              string
+	resourceName      v1.ResourceName
+	deviceFileRegexp  string
+	envVars           []string
+	verificationImage string
+}
+
+var acceleratorProfiles = []acceleratorProfile{
+	{
+		name:              "nvidia.com/gpu",
+		resourceName:      v1.ResourceNvidiaGPU,
+		deviceFileRegexp:  `^nvidia[0-9]+$`,
+		verificationImage: "gcr.io/google_containers/busybox:1.24",
+	},
+	{
+		name:              "amd.com/gpu",
+		resourceName:      v1.ResourceName("amd.com/gpu"),
+		deviceFileRegexp:  `^card[0-9]+$`,
+		verificationImage: "gcr.io/google_containers/busybox:1.24",
+	},
+	{
+		name:              "gpu.intel.com/i915",
+		resourceName:      v1.ResourceName("gpu.intel.com/i915"),
+		deviceFileRegexp:  `^renderD[0-9]+$`,
+		verificationImage: "gcr.io/google_containers/busybox:1.24",
+	},
+	{
+		name:              "aws.amazon.com/neuron",
+		resourceName:      v1.ResourceName("aws.amazon.com/neuron"),
+		deviceFileRegexp:  `^neuron[0-9]+$`,
+		envVars:           []string{"NEURON_RT_VISIBLE_CORES"},
+		verificationImage: "gcr.io/google_containers/busybox:1.24",
+	},
+	{
+		name:              "aws.amazon.com/neuroncore",
+		resourceName:      v1.ResourceName("aws.amazon.com/neuroncore"),
+		deviceFileRegexp:  `^neuron[0-9]+$`,
+		envVars:           []string{"NEURON_RT_VISIBLE_CORES"},
+		verificationImage: "gcr.io/google_containers/busybox:1.24",
+	},
+}
+
+// migProfile describes a single NVIDIA MIG (Multi-Instance GPU) partition
+// size, e.g. "1g.5gb" for a 1-compute-unit/5GB slice.
+type migProfile struct {
+	sliceLabel   string
+	mixedResName v1.ResourceName
+}
+
+var migProfiles = []migProfile{
+	{sliceLabel: "1g.5gb", mixedResName: v1.ResourceName("nvidia.com/mig-1g.5gb")},
+	{sliceLabel: "2g.10gb", mixedResName: v1.ResourceName("nvidia.com/mig-2g.10gb")},
+}
+
+// migSliceEnvVar is injected by a MIG device plugin's Allocate call with the
+// ID of whichever device it actually handed the container, so makeMIGPod can
+// assert against a real allocation result instead of an incidental one.
+const migSliceEnvVar = "NVIDIA_MIG_SLICE"
+
+// migSliceAllocateEnv reports the device ID the kubelet picked via
+// containerReq.DevicesIDs, keyed to migSliceEnvVar. Every migProfile's fake
+// plugin advertises exactly one device, named after its slice label, so this
+// ties the env var a pod sees to the specific slice its plugin allocated.
+func migSliceAllocateEnv(deviceIDs []string) map[string]string {
+	return map[string]string{migSliceEnvVar: deviceIDs[0]}
+}
+
+// migSingleStrategyResourceName stands in for nvidia.com/gpu under the
+// "single" MIG strategy. It deliberately isn't v1.ResourceNvidiaGPU: that
+// resource is resynced by the kubelet's own accelerator manager from /dev,
+// so a real single-strategy plugin claiming it requires actual GPU hardware.
+// A distinctly-named resource lets the test exercise the single-strategy
+// allocation flow without fighting that resync.
+const migSingleStrategyResourceName = v1.ResourceName("nvidia.com/mig-single-strategy-gpu")
+
+// gpuToolsImage bundles the NVIDIA management tools (nvidia-smi) the MIG and
+// topology tests parse the output of. busybox doesn't ship it, so these
+// tests need a real tooling image; CI wires one in via this flag.
+var gpuToolsImage = flag.String("gpu-tools-image", "", "Image bundling nvidia-smi, used to verify MIG slice and GPU topology assignment. If unset, tests that need it are skipped.")
+
+// reconfigureKubeletForTest applies mutate to a copy of the node's current
+// kubelet configuration, pushes it via dynamic kubelet configuration, and
+// returns a function that restores the original configuration. This suite is
+// Serial because reconfiguring is disruptive, not because one spec's
+// reconfiguration is still in effect for the next one - every spec that needs
+// kubelet state must set it up itself rather than relying on a sibling
+// Context having left it that way. Skips the calling spec if dynamic kubelet
+// configuration isn't enabled on this node.
+func reconfigureKubeletForTest(f *framework.Framework, mutate func(cfg *componentconfig.KubeletConfiguration)) func() {
+	By("ensuring that dynamic kubelet configuration is enabled")
+	enabled, err := isKubeletConfigEnabled(f)
+	framework.ExpectNoError(err)
+	if !enabled {
+		Skip("Dynamic Kubelet configuration is not enabled. Skipping test.")
+	}
+
+	oldCfg, err := getCurrentKubeletConfig()
+	framework.ExpectNoError(err)
+	clone, err := api.Scheme.DeepCopy(oldCfg)
+	framework.ExpectNoError(err)
+	newCfg := clone.(*componentconfig.KubeletConfiguration)
+	mutate(newCfg)
+	framework.ExpectNoError(setKubeletConfiguration(f, newCfg))
+
+	return func() {
+		framework.ExpectNoError(setKubeletConfiguration(f, oldCfg))
+	}
+}
+
+// enableAcceleratorsFeatureGate mutates cfg to turn on the Accelerators
+// feature gate alongside whatever feature gates were already configured, so
+// node.Status.Capacity reports GPUs.
+func enableAcceleratorsFeatureGate(cfg *componentconfig.KubeletConfiguration) {
+	if cfg.FeatureGates != "" {
+		cfg.FeatureGates = fmt.Sprintf("%s,%s", acceleratorsFeatureGate, cfg.FeatureGates)
+	} else {
+		cfg.FeatureGates = acceleratorsFeatureGate
+	}
+}
+
 // Serial because the test updates kubelet configuration.
 var _ = framework.KubeDescribe("GPU [Serial]", func() {
 	f := framework.NewDefaultFramework("gpu-test")
@@ -108,6 +293,297 @@ var _ = framework.KubeDescribe("GPU [Serial]", func() {
 			framework.ExpectNoError(f.PodClient().MatchContainerOutput(podFailure.Name, podFailure.Name, "Success"))
 		})
 	})
+
+	Context("shared GPU by memory", func() {
+		It("should schedule multiple pods sharing a single GPU by memory request", func() {
+			By("starting a gpushare-style device plugin that advertises the GPU as memory units")
+			const totalGPUMemUnits = 16
+			plugin := newFakeDevicePlugin(gpuMemResourceName, totalGPUMemUnits, func(deviceIDs []string) map[string]string {
+				return map[string]string{
+					"NVIDIA_VISIBLE_DEVICES": "0",
+					"GPU_MEM_LIMIT":          fmt.Sprintf("%d", int64(len(deviceIDs))*gpuMemUnitMiB),
+				}
+			})
+			framework.ExpectNoError(plugin.Start())
+			defer plugin.Stop()
+
+			By("creating pods that each request a slice of the GPU's memory")
+			podA := makeGPUMemPod("gpu-mem-a", totalGPUMemUnits/2)
+			podB := makeGPUMemPod("gpu-mem-b", totalGPUMemUnits/2)
+			podA = f.PodClient().CreateSync(podA)
+			podB = f.PodClient().CreateSync(podB)
+
+			By("verifying both pods are running concurrently with their requested memory slice passed through")
+			framework.ExpectNoError(f.PodClient().MatchContainerOutput(podA.Name, podA.Name, "Success"))
+			framework.ExpectNoError(f.PodClient().MatchContainerOutput(podB.Name, podB.Name, "Success"))
+
+			By("verifying the kubelet's /pods endpoint lists both pods, independent of apiserver state")
+			kubeletPods, err := getKubeletRunningPods()
+			if err == errKubeletReadOnlyPortDisabled {
+				framework.Logf("kubelet read-only port is disabled on this node, skipping the /pods cross-check")
+			} else {
+				framework.ExpectNoError(err, "querying kubelet /pods endpoint")
+				Expect(podNames(kubeletPods)).To(ContainElement(podA.Name))
+				Expect(podNames(kubeletPods)).To(ContainElement(podB.Name))
+			}
+
+			By("creating a third pod that asks for more memory than remains on the GPU")
+			podOverCommit := makeGPUMemPod("gpu-mem-overcommit", totalGPUMemUnits)
+			podOverCommit = f.PodClient().Create(podOverCommit)
+			framework.ExpectNoError(framework.WaitForPodCondition(f.ClientSet, f.Namespace.Name, podOverCommit.Name, "pod rejected", framework.PodStartTimeout, func(pod *v1.Pod) (bool, error) {
+				if pod.Status.Phase == v1.PodFailed {
+					return true, nil
+				}
+				return false, nil
+			}))
+
+			By("cleaning up the sharing pods")
+			gp := int64(0)
+			deleteOptions := metav1.DeleteOptions{GracePeriodSeconds: &gp}
+			f.PodClient().DeleteSync(podA.Name, &deleteOptions, 30*time.Second)
+			f.PodClient().DeleteSync(podB.Name, &deleteOptions, 30*time.Second)
+			f.PodClient().DeleteSync(podOverCommit.Name, &deleteOptions, 30*time.Second)
+		})
+	})
+
+	Context("multiple accelerator vendors", func() {
+		It("should allocate whichever accelerator extended resources the node advertises", func() {
+			By("Getting the local node object from the api server")
+			nodeList, err := f.ClientSet.Core().Nodes().List(metav1.ListOptions{})
+			framework.ExpectNoError(err, "getting node list")
+			Expect(len(nodeList.Items)).To(Equal(1))
+			node := nodeList.Items[0]
+
+			ranAny := false
+			for _, profile := range acceleratorProfiles {
+				profile := profile
+				available, ok := node.Status.Capacity[profile.resourceName]
+				if !ok || available.IsZero() {
+					framework.Logf("Skipping accelerator profile %s: not advertised by node", profile.name)
+					continue
+				}
+				ranAny = true
+
+				By(fmt.Sprintf("Creating a pod that consumes all %s resources", profile.name))
+				podSuccess := makeAcceleratorPod(profile, available.Value(), profile.name+"-success")
+				podSuccess = f.PodClient().CreateSync(podSuccess)
+
+				By(fmt.Sprintf("Checking if the %s pod outputted Success to its logs", profile.name))
+				framework.ExpectNoError(f.PodClient().MatchContainerOutput(podSuccess.Name, podSuccess.Name, "Success"))
+
+				By(fmt.Sprintf("Creating a new pod requesting a %s unit and noticing that it is rejected by the Kubelet", profile.name))
+				podFailure := makeAcceleratorPod(profile, 1, profile.name+"-failure")
+				podFailure = f.PodClient().Create(podFailure)
+				framework.ExpectNoError(framework.WaitForPodCondition(f.ClientSet, f.Namespace.Name, podFailure.Name, "pod rejected", framework.PodStartTimeout, func(pod *v1.Pod) (bool, error) {
+					if pod.Status.Phase == v1.PodFailed {
+						return true, nil
+					}
+					return false, nil
+				}))
+
+				gp := int64(0)
+				deleteOptions := metav1.DeleteOptions{GracePeriodSeconds: &gp}
+				f.PodClient().DeleteSync(podSuccess.Name, &deleteOptions, 30*time.Second)
+				f.PodClient().DeleteSync(podFailure.Name, &deleteOptions, 10*time.Second)
+			}
+
+			if !ranAny {
+				Skip("No accelerator extended resources available on local node. Skipping test.")
+			}
+		})
+	})
+
+	Context("MIG partitioning", func() {
+		It("should allocate MIG slices advertised under the single strategy", func() {
+			if *gpuToolsImage == "" {
+				Skip("--gpu-tools-image not set. Skipping MIG partitioning test.")
+			}
+
+			By("starting a fake device plugin that advertises a single MIG slice under a single shared resource")
+			plugin := newFakeDevicePluginWithIDs(migSingleStrategyResourceName, []string{migProfiles[0].sliceLabel}, migSliceAllocateEnv)
+			framework.ExpectNoError(plugin.Start())
+			defer plugin.Stop()
+
+			By("creating a pod requesting a MIG slice and checking it was handed the slice the plugin allocated")
+			pod := makeMIGPod(migSingleStrategyResourceName, migProfiles[0].sliceLabel, "mig-single-success")
+			pod = f.PodClient().CreateSync(pod)
+			framework.ExpectNoError(f.PodClient().MatchContainerOutput(pod.Name, pod.Name, "Success"))
+
+			gp := int64(0)
+			f.PodClient().DeleteSync(pod.Name, &metav1.DeleteOptions{GracePeriodSeconds: &gp}, 30*time.Second)
+		})
+
+		It("should allocate MIG slices advertised under the mixed strategy", func() {
+			if *gpuToolsImage == "" {
+				Skip("--gpu-tools-image not set. Skipping MIG partitioning test.")
+			}
+
+			By("starting one fake device plugin per MIG profile, each advertising its own extended resource")
+			var plugins []*fakeDevicePlugin
+			defer func() {
+				for _, plugin := range plugins {
+					plugin.Stop()
+				}
+			}()
+			for _, profile := range migProfiles {
+				plugin := newFakeDevicePluginWithIDs(profile.mixedResName, []string{profile.sliceLabel}, migSliceAllocateEnv)
+				framework.ExpectNoError(plugin.Start())
+				plugins = append(plugins, plugin)
+			}
+
+			By("creating a pod per MIG profile and checking each was handed the slice the plugin allocated")
+			var pods []*v1.Pod
+			for _, profile := range migProfiles {
+				pod := makeMIGPod(profile.mixedResName, profile.sliceLabel, "mig-mixed-"+profile.sliceLabel)
+				pods = append(pods, f.PodClient().CreateSync(pod))
+			}
+			for i, profile := range migProfiles {
+				framework.ExpectNoError(f.PodClient().MatchContainerOutput(pods[i].Name, pods[i].Name, "Success"), "verifying profile %s", profile.sliceLabel)
+			}
+
+			By("requesting a MIG profile with zero capacity, advertised by no plugin, and noticing it is rejected")
+			unavailableProfile := migProfile{sliceLabel: "3g.20gb", mixedResName: v1.ResourceName("nvidia.com/mig-3g.20gb")}
+			podFailure := makeMIGPod(unavailableProfile.mixedResName, unavailableProfile.sliceLabel, "mig-zero-capacity")
+			podFailure = f.PodClient().Create(podFailure)
+			framework.ExpectNoError(framework.WaitForPodCondition(f.ClientSet, f.Namespace.Name, podFailure.Name, "pod rejected", framework.PodStartTimeout, func(pod *v1.Pod) (bool, error) {
+				if pod.Status.Phase == v1.PodFailed {
+					return true, nil
+				}
+				return false, nil
+			}))
+
+			gp := int64(0)
+			deleteOptions := metav1.DeleteOptions{GracePeriodSeconds: &gp}
+			for _, pod := range pods {
+				f.PodClient().DeleteSync(pod.Name, &deleteOptions, 30*time.Second)
+			}
+			f.PodClient().DeleteSync(podFailure.Name, &deleteOptions, 10*time.Second)
+		})
+	})
+
+	Context("workload verification", func() {
+		It("should run a real inference workload on the allocated GPU and meet the throughput floor", func() {
+			if *gpuWorkloadImage == "" {
+				Skip("--gpu-workload-image not set. Skipping GPU workload verification test.")
+			}
+
+			By("enabling support for GPUs")
+			restore := reconfigureKubeletForTest(f, enableAcceleratorsFeatureGate)
+			defer restore()
+
+			By("Getting the local node object from the api server")
+			nodeList, err := f.ClientSet.Core().Nodes().List(metav1.ListOptions{})
+			framework.ExpectNoError(err, "getting node list")
+			Expect(len(nodeList.Items)).To(Equal(1))
+			node := nodeList.Items[0]
+			gpusAvailable := node.Status.Capacity.NvidiaGPU()
+			By("Skipping the test if GPUs aren't available")
+			if gpusAvailable.IsZero() {
+				Skip("No GPUs available on local node. Skipping test.")
+			}
+
+			By("Running a small real inference workload on the allocated GPU(s)")
+			pod := makeGPUWorkloadPod(*gpuWorkloadImage, gpusAvailable.Value(), "gpu-workload")
+			pod = f.PodClient().CreateSync(pod)
+
+			By("Checking the workload initialized on the expected CUDA device and cleared the throughput floor")
+			framework.ExpectNoError(f.PodClient().MatchContainerOutput(pod.Name, pod.Name, "Success"))
+
+			gp := int64(0)
+			f.PodClient().DeleteSync(pod.Name, &metav1.DeleteOptions{GracePeriodSeconds: &gp}, 30*time.Second)
+		})
+	})
+
+	Context("compute policy", func() {
+		It("should enforce fixed-share, burst-share and native-burst-share compute policies, and reject an unknown policy", func() {
+			By("starting fake device plugins backing the fixed-share, burst-share and native-burst-share compute policies")
+			fixedSharePlugin := newFakeDevicePlugin(computePolicyFixedShareResourceName, 1, func(deviceIDs []string) map[string]string {
+				return map[string]string{"CUDA_COMPUTE_LIMIT": fmt.Sprintf("%d", fixedShareComputeLimit)}
+			})
+			framework.ExpectNoError(fixedSharePlugin.Start())
+			defer fixedSharePlugin.Stop()
+
+			burstSharePlugin := newFakeDevicePlugin(computePolicyBurstShareResourceName, 1, func(deviceIDs []string) map[string]string {
+				return map[string]string{"GPU_BURST": "true"}
+			})
+			framework.ExpectNoError(burstSharePlugin.Start())
+			defer burstSharePlugin.Stop()
+
+			nativeBurstSharePlugin := newFakeDevicePlugin(computePolicyNativeBurstShareResourceName, 1, func(deviceIDs []string) map[string]string {
+				return map[string]string{"GPU_BURST": "true"}
+			})
+			framework.ExpectNoError(nativeBurstSharePlugin.Start())
+			defer nativeBurstSharePlugin.Stop()
+
+			By("creating a pod requesting the fixed-share policy and checking its compute limit env var")
+			podFixed := makeComputePolicyPod("gpu-fixed-share", computePolicyFixedShareResourceName, computePolicyFixedShare)
+			podFixed = f.PodClient().CreateSync(podFixed)
+			framework.ExpectNoError(f.PodClient().MatchContainerOutput(podFixed.Name, podFixed.Name, "Success"))
+
+			By("creating a pod requesting the burst-share policy and checking the limit env var is absent")
+			podBurst := makeComputePolicyPod("gpu-burst-share", computePolicyBurstShareResourceName, computePolicyBurstShare)
+			podBurst = f.PodClient().CreateSync(podBurst)
+			framework.ExpectNoError(f.PodClient().MatchContainerOutput(podBurst.Name, podBurst.Name, "Success"))
+
+			By("creating a pod requesting the native-burst-share policy and checking the limit env var is absent")
+			podNativeBurst := makeComputePolicyPod("gpu-native-burst-share", computePolicyNativeBurstShareResourceName, computePolicyNativeBurstShare)
+			podNativeBurst = f.PodClient().CreateSync(podNativeBurst)
+			framework.ExpectNoError(f.PodClient().MatchContainerOutput(podNativeBurst.Name, podNativeBurst.Name, "Success"))
+
+			By("creating a pod requesting a compute policy resource nothing advertises and noticing it is rejected by the Kubelet")
+			podUnknownPolicy := makeComputePolicyPod("gpu-unknown-policy", computePolicyUnsupportedResourceName, "unsupported-policy")
+			podUnknownPolicy = f.PodClient().Create(podUnknownPolicy)
+			framework.ExpectNoError(framework.WaitForPodCondition(f.ClientSet, f.Namespace.Name, podUnknownPolicy.Name, "pod rejected", framework.PodStartTimeout, func(pod *v1.Pod) (bool, error) {
+				if pod.Status.Phase == v1.PodFailed {
+					return true, nil
+				}
+				return false, nil
+			}))
+
+			gp := int64(0)
+			deleteOptions := metav1.DeleteOptions{GracePeriodSeconds: &gp}
+			f.PodClient().DeleteSync(podFixed.Name, &deleteOptions, 30*time.Second)
+			f.PodClient().DeleteSync(podBurst.Name, &deleteOptions, 30*time.Second)
+			f.PodClient().DeleteSync(podNativeBurst.Name, &deleteOptions, 30*time.Second)
+			f.PodClient().DeleteSync(podUnknownPolicy.Name, &deleteOptions, 10*time.Second)
+		})
+	})
+
+	Context("topology-aware allocation", func() {
+		It("should prefer GPUs sharing an NVLink/NVSwitch domain when the topology manager policy is best-effort", func() {
+			if *gpuToolsImage == "" {
+				Skip("--gpu-tools-image not set. Skipping topology test.")
+			}
+
+			By("enabling support for GPUs and the best-effort topology manager policy")
+			restore := reconfigureKubeletForTest(f, func(cfg *componentconfig.KubeletConfiguration) {
+				enableAcceleratorsFeatureGate(cfg)
+				cfg.TopologyManagerPolicy = topologyManagerBestEffortPolicy
+			})
+			defer restore()
+
+			By("Getting the local node object from the api server")
+			nodeList, err := f.ClientSet.Core().Nodes().List(metav1.ListOptions{})
+			framework.ExpectNoError(err, "getting node list")
+			Expect(len(nodeList.Items)).To(Equal(1))
+			node := nodeList.Items[0]
+			// This check can't be hoisted above the reconfiguration above: GPU
+			// capacity is only observable once the Accelerators gate this spec
+			// just enabled has taken effect.
+			gpusAvailable := node.Status.Capacity.NvidiaGPU()
+			if gpusAvailable.Value() < 2 {
+				Skip("Fewer than 2 GPUs available on local node. Skipping topology test.")
+			}
+
+			By("creating a pod requesting 2 GPUs and checking they share an NVLink/NVSwitch domain")
+			pod := makeTopologyPod(2, "gpu-topology")
+			pod = f.PodClient().CreateSync(pod)
+			framework.ExpectNoError(f.PodClient().MatchContainerOutput(pod.Name, pod.Name, "Success"))
+
+			gp := int64(0)
+			f.PodClient().DeleteSync(pod.Name, &metav1.DeleteOptions{GracePeriodSeconds: &gp}, 30*time.Second)
+		})
+	})
 })
 
 func makePod(gpus int64, name string) *v1.Pod {
@@ -133,3 +609,250 @@ func makePod(gpus int64, name string) *v1.Pod {
 		},
 	}
 }
+
+// makeAcceleratorPod builds a pod requesting count units of profile's
+// extended resource. The verification command checks that the container sees
+// exactly count device files matching the profile's device file pattern and
+// that any env vars the profile expects from the device plugin are present.
+func makeAcceleratorPod(profile acceleratorProfile, count int64, name string) *v1.Pod {
+	resources := v1.ResourceRequirements{
+		Limits: v1.ResourceList{
+			profile.resourceName: *resource.NewQuantity(count, resource.DecimalSI),
+		},
+	}
+	verificationCmd := fmt.Sprintf("if [[ %d -ne $(ls /dev/ | egrep -c '%s') ]]; then exit 1; fi; ", count, profile.deviceFileRegexp)
+	for _, envVar := range profile.envVars {
+		verificationCmd += fmt.Sprintf("if [[ -z \"$%s\" ]]; then exit 1; fi; ", envVar)
+	}
+	verificationCmd += "echo Success; sleep 10240 "
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Image:     profile.verificationImage,
+					Name:      name,
+					Command:   []string{"sh", "-c", verificationCmd},
+					Resources: resources,
+				},
+			},
+		},
+	}
+}
+
+// makeMIGPod builds a pod that requests one unit of resourceName (a MIG
+// slice, advertised either under migSingleStrategyResourceName, standing in
+// for nvidia.com/gpu, under the "single" strategy or under its own
+// profile-specific resource name under the "mixed" strategy).
+// It verifies both that the plugin's Allocate call actually handed it the
+// requested slice (via migSliceEnvVar, which the plugin sets from the real
+// device ID the kubelet picked) and that `nvidia-smi -L` agrees.
+func makeMIGPod(resourceName v1.ResourceName, sliceLabel string, name string) *v1.Pod {
+	resources := v1.ResourceRequirements{
+		Limits: v1.ResourceList{
+			resourceName: *resource.NewQuantity(1, resource.DecimalSI),
+		},
+	}
+	verificationCmd := fmt.Sprintf(
+		"if [[ \"$%s\" != %q ]]; then exit 1; fi; if ! nvidia-smi -L | grep -q %q; then exit 1; fi; echo Success; sleep 10240 ",
+		migSliceEnvVar, sliceLabel, sliceLabel)
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Image:     *gpuToolsImage,
+					Name:      name,
+					Command:   []string{"sh", "-c", verificationCmd},
+					Resources: resources,
+				},
+			},
+		},
+	}
+}
+
+// makeGPUWorkloadPod builds a pod that runs a real inference workload on the
+// allocated GPU(s) rather than just checking for device file presence. The
+// workload image is expected to load its bundled model on CUDA device 0,
+// run a handful of inference iterations, emit structured
+// "throughput=<ips> latency_ms=<ms>" lines, and print Success only once
+// throughput has cleared gpuWorkloadMinThroughput within gpuWorkloadTimeout.
+func makeGPUWorkloadPod(image string, gpus int64, name string) *v1.Pod {
+	resources := v1.ResourceRequirements{
+		Limits: v1.ResourceList{
+			v1.ResourceNvidiaGPU: *resource.NewQuantity(gpus, resource.DecimalSI),
+		},
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Image:     image,
+					Name:      name,
+					Resources: resources,
+					Env: []v1.EnvVar{
+						{Name: "CUDA_VISIBLE_DEVICES", Value: "0"},
+						{Name: "GPU_WORKLOAD_MIN_THROUGHPUT", Value: fmt.Sprintf("%d", gpuWorkloadMinThroughput)},
+						{Name: "GPU_WORKLOAD_TIMEOUT_SECONDS", Value: fmt.Sprintf("%d", int64(gpuWorkloadTimeout.Seconds()))},
+					},
+				},
+			},
+		},
+	}
+}
+
+// makeComputePolicyPod builds a pod requesting resourceName - a fake extended
+// resource backed by a compute-policy-aware fakeDevicePlugin, or, for an
+// unsupported policy, one nothing advertises - under the given compute
+// policy annotation. CUDA_COMPUTE_LIMIT and GPU_BURST are expected to be
+// injected by that plugin's Allocate call, not authored by the pod itself;
+// the verification command only checks the env vars it was handed.
+func makeComputePolicyPod(name string, resourceName v1.ResourceName, policy string) *v1.Pod {
+	resources := v1.ResourceRequirements{
+		Limits: v1.ResourceList{
+			resourceName: *resource.NewQuantity(1, resource.DecimalSI),
+		},
+	}
+
+	var verificationCmd string
+	switch policy {
+	case computePolicyFixedShare:
+		verificationCmd = fmt.Sprintf("if [[ \"$CUDA_COMPUTE_LIMIT\" != \"%d\" ]]; then exit 1; fi; echo Success; sleep 10240 ", fixedShareComputeLimit)
+	case computePolicyBurstShare, computePolicyNativeBurstShare:
+		verificationCmd = "if [[ -n \"$CUDA_COMPUTE_LIMIT\" ]]; then exit 1; fi; if [[ \"$GPU_BURST\" != \"true\" ]]; then exit 1; fi; echo Success; sleep 10240 "
+	default:
+		// Nothing advertises resourceName, so this pod is never expected to
+		// start; the "unknown policy" sub-test exercises the kubelet's
+		// genuine admission-time resource-insufficiency rejection instead.
+		verificationCmd = "echo Success; sleep 10240 "
+	}
+
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				computePolicyAnnotation: policy,
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Image:     "gcr.io/google_containers/busybox:1.24",
+					Name:      name,
+					Command:   []string{"sh", "-c", verificationCmd},
+					Resources: resources,
+				},
+			},
+		},
+	}
+}
+
+// makeTopologyPod builds a pod requesting gpus NvidiaGPU devices and verifies,
+// via a small `nvidia-smi topo -m` parser, that the GPUs it was handed share
+// an NVLink/NVSwitch interconnect domain rather than being connected only
+// through the host bridge or a different NUMA node.
+func makeTopologyPod(gpus int64, name string) *v1.Pod {
+	resources := v1.ResourceRequirements{
+		Limits: v1.ResourceList{
+			v1.ResourceNvidiaGPU: *resource.NewQuantity(gpus, resource.DecimalSI),
+		},
+	}
+	verificationCmd := "if ! nvidia-smi topo -m | grep -qE 'NV[0-9]+'; then exit 1; fi; echo Success; sleep 10240 "
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Image:     *gpuToolsImage,
+					Name:      name,
+					Command:   []string{"sh", "-c", verificationCmd},
+					Resources: resources,
+				},
+			},
+		},
+	}
+}
+
+// makeGPUMemPod builds a pod that requests memUnits units of GPU memory
+// through the gpushare-style extended resource rather than a whole NvidiaGPU
+// device. NVIDIA_VISIBLE_DEVICES and GPU_MEM_LIMIT are expected to be
+// injected by the fake gpushare device plugin's Allocate call, not authored
+// by the pod itself; the verification command only tells the container what
+// limit to expect, then checks the plugin-injected value against it.
+func makeGPUMemPod(name string, memUnits int64) *v1.Pod {
+	resources := v1.ResourceRequirements{
+		Limits: v1.ResourceList{
+			gpuMemResourceName: *resource.NewQuantity(memUnits, resource.DecimalSI),
+		},
+	}
+	gpuverificationCmd := fmt.Sprintf("if [[ -z \"$NVIDIA_VISIBLE_DEVICES\" ]]; then exit 1; fi; "+
+		"if [[ \"$GPU_MEM_LIMIT\" != \"$EXPECTED_GPU_MEM_LIMIT\" ]]; then exit 1; fi; echo Success; sleep 10240 ")
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Image:   "gcr.io/google_containers/busybox:1.24",
+					Name:    name,
+					Command: []string{"sh", "-c", gpuverificationCmd},
+					Env: []v1.EnvVar{
+						{Name: "EXPECTED_GPU_MEM_LIMIT", Value: fmt.Sprintf("%d", memUnits*gpuMemUnitMiB)},
+					},
+					Resources: resources,
+				},
+			},
+		},
+	}
+}
+
+// errKubeletReadOnlyPortDisabled is returned by getKubeletRunningPods when
+// the node's kubelet has its read-only port turned off (a common hardened
+// default), so callers can treat the /pods cross-check as unavailable
+// instead of failing outright.
+var errKubeletReadOnlyPortDisabled = fmt.Errorf("kubelet read-only port is disabled")
+
+// getKubeletRunningPods queries the kubelet's own /pods endpoint directly,
+// rather than the apiserver, so tests relying on it stay robust under
+// apiserver latency or caching. The port is read from the kubelet's live
+// configuration rather than assumed, since it's commonly disabled or moved
+// off its default in hardened configurations.
+func getKubeletRunningPods() ([]v1.Pod, error) {
+	cfg, err := getCurrentKubeletConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ReadOnlyPort == 0 {
+		return nil, errKubeletReadOnlyPortDisabled
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/pods", cfg.ReadOnlyPort))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var podList v1.PodList
+	if err := json.NewDecoder(resp.Body).Decode(&podList); err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+func podNames(pods []v1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}